@@ -0,0 +1,124 @@
+package redis_idgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/chaos-io/idgen"
+)
+
+const refillGroupKey = "refill"
+
+// CachedGenerator wraps an idgen.IIDGenerator with an in-memory ring of
+// pre-allocated IDs, so the hot path GenID becomes a lock-protected slice pop
+// instead of a synchronous Redis round trip. The ring is refilled in batches
+// from the wrapped generator; concurrent refills are coalesced with
+// singleflight so that a drained ring under concurrent load triggers exactly
+// one call to the wrapped generator, with every other caller blocking on
+// that same call.
+type CachedGenerator struct {
+	inner        idgen.IIDGenerator
+	batchSize    int
+	lowWatermark int
+
+	mu    sync.Mutex
+	ring  []int64
+	group singleflight.Group
+}
+
+// NewCachedIDGenerator wraps inner with a ring of pre-allocated IDs of size
+// batchSize. Once the ring drops below lowWatermark, a background refill is
+// kicked off so the ring rarely runs dry under steady load.
+//
+// batchSize must be positive (a zero batchSize would refill the ring with
+// zero IDs forever, livelocking GenID) and lowWatermark must be in
+// [0, batchSize), so it returns an error instead of building a generator
+// that can hang or panic.
+func NewCachedIDGenerator(inner idgen.IIDGenerator, batchSize int, lowWatermark int) (idgen.IIDGenerator, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("cached idgen batchSize must be positive, got %d", batchSize)
+	}
+	if lowWatermark < 0 || lowWatermark >= batchSize {
+		return nil, fmt.Errorf("cached idgen lowWatermark must be in [0, batchSize=%d), got %d", batchSize, lowWatermark)
+	}
+
+	return &CachedGenerator{
+		inner:        inner,
+		batchSize:    batchSize,
+		lowWatermark: lowWatermark,
+	}, nil
+}
+
+func (g *CachedGenerator) GenID(ctx context.Context) (int64, error) {
+	for {
+		if id, ok := g.take(); ok {
+			return id, nil
+		}
+		if err := g.refill(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (g *CachedGenerator) GenMultiIDs(ctx context.Context, counts int) ([]int64, error) {
+	ids := make([]int64, 0, counts)
+	for len(ids) < counts {
+		id, err := g.GenID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// take pops one ID off the ring, kicking off a background refill once the
+// ring drops below lowWatermark.
+func (g *CachedGenerator) take() (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.ring) == 0 {
+		return 0, false
+	}
+
+	id := g.ring[0]
+	g.ring = g.ring[1:]
+
+	if len(g.ring) < g.lowWatermark {
+		g.refillAsync()
+	}
+
+	return id, true
+}
+
+// refill blocks until the ring has been topped up, coalescing concurrent
+// callers into a single call to inner.GenMultiIDs via singleflight. It
+// appends to the ring rather than sending on a fixed-size channel, so a
+// refill can never block on a reader that never shows up.
+func (g *CachedGenerator) refill(ctx context.Context) error {
+	_, err, _ := g.group.Do(refillGroupKey, func() (interface{}, error) {
+		ids, err := g.inner.GenMultiIDs(ctx, g.batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		g.mu.Lock()
+		g.ring = append(g.ring, ids...)
+		g.mu.Unlock()
+
+		return nil, nil
+	})
+	return err
+}
+
+// refillAsync triggers a refill in the background without making the caller
+// that is still draining the ring wait for it.
+func (g *CachedGenerator) refillAsync() {
+	go func() {
+		_ = g.refill(context.Background())
+	}()
+}
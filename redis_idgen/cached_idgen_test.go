@@ -0,0 +1,139 @@
+package redis_idgen
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGenerator is a minimal idgen.IIDGenerator backed by an atomic counter,
+// used to drive CachedGenerator without a real Redis.
+type fakeGenerator struct {
+	next  int64
+	delay time.Duration
+}
+
+func (f *fakeGenerator) GenID(ctx context.Context) (int64, error) {
+	ids, err := f.GenMultiIDs(ctx, 1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (f *fakeGenerator) GenMultiIDs(ctx context.Context, counts int) ([]int64, error) {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+
+	ids := make([]int64, counts)
+	for i := range ids {
+		ids[i] = atomic.AddInt64(&f.next, 1)
+	}
+	return ids, nil
+}
+
+func TestNewCachedIDGeneratorRejectsInvalidConfig(t *testing.T) {
+	cases := []struct {
+		name         string
+		batchSize    int
+		lowWatermark int
+	}{
+		{"zero batch size", 0, 0},
+		{"negative batch size", -1, 0},
+		{"negative low watermark", 4, -1},
+		{"low watermark equal to batch size", 4, 4},
+		{"low watermark above batch size", 4, 5},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := NewCachedIDGenerator(&fakeGenerator{}, c.batchSize, c.lowWatermark); err == nil {
+				t.Fatalf("expected error for batchSize=%d lowWatermark=%d", c.batchSize, c.lowWatermark)
+			}
+		})
+	}
+}
+
+func TestCachedGeneratorRefillsWithoutDuplicates(t *testing.T) {
+	g, err := NewCachedIDGenerator(&fakeGenerator{}, 4, 1)
+	if err != nil {
+		t.Fatalf("NewCachedIDGenerator: %v", err)
+	}
+
+	seen := make(map[int64]bool)
+	for i := 0; i < 10; i++ {
+		id, err := g.GenID(context.Background())
+		if err != nil {
+			t.Fatalf("GenID: %v", err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+// TestCachedGeneratorSurvivesBurstThenIdle covers a burst that drains the
+// ring below lowWatermark (triggering a background refill) after which
+// traffic stops; the test completing shows that GenID returns throughout the
+// burst while that refill is outstanding.
+func TestCachedGeneratorSurvivesBurstThenIdle(t *testing.T) {
+	g, err := NewCachedIDGenerator(&fakeGenerator{}, 4, 3)
+	if err != nil {
+		t.Fatalf("NewCachedIDGenerator: %v", err)
+	}
+
+	// With batchSize 4 and lowWatermark 3 the ring holds 3, 2, then 1 IDs
+	// after these calls, so the second call starts a background refill.
+	for i := 0; i < 3; i++ {
+		if _, err := g.GenID(context.Background()); err != nil {
+			t.Fatalf("GenID: %v", err)
+		}
+	}
+	// No further calls: the background refill is left to finish on its own;
+	// the test does not wait for it or check on it.
+}
+
+func TestCachedGeneratorConcurrentRefillsCoalesceWithoutDuplicates(t *testing.T) {
+	inner := &fakeGenerator{delay: 10 * time.Millisecond}
+	g, err := NewCachedIDGenerator(inner, 50, 5)
+	if err != nil {
+		t.Fatalf("NewCachedIDGenerator: %v", err)
+	}
+
+	const callers = 200
+	results := make(chan int64, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := g.GenID(context.Background())
+			if err != nil {
+				t.Errorf("GenID: %v", err)
+				return
+			}
+			results <- id
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[int64]bool)
+	for id := range results {
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+
+	// Without coalescing, every caller that finds the ring empty would call
+	// inner on its own and allocate a batch of 50 IDs; with it, only a few
+	// batches beyond the 4 needed for 200 callers are ever requested.
+	if allocated := atomic.LoadInt64(&inner.next); allocated > 10*50 {
+		t.Fatalf("inner allocated %d IDs for %d callers, refills were not coalesced", allocated, callers)
+	}
+}
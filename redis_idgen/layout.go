@@ -0,0 +1,140 @@
+package redis_idgen
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout describes how a 64-bit ID is packed, from the most to the least
+// significant bit: TimestampBits of elapsed time since Epoch, an optional
+// SubMsBits sub-millisecond component, CounterBits of per-millisecond
+// counter, and ServerIDBits of server identity.
+type Layout struct {
+	TimestampBits int
+	SubMsBits     int
+	CounterBits   int
+	ServerIDBits  int
+	Epoch         time.Time
+}
+
+// LegacyLayout is the original 32-bit-seconds + 10-bit-millis + 8-bit-counter
+// + 14-bit-serverID layout used by NewIDGenerator. Its 32-bit seconds
+// component overflows in 2038 and its 14-bit serverID caps deployments at
+// 16384 workers; prefer SnowflakeLayout for new deployments.
+var LegacyLayout = Layout{
+	TimestampBits: 32,
+	SubMsBits:     10,
+	CounterBits:   8,
+	ServerIDBits:  14,
+	Epoch:         time.Unix(0, 0),
+}
+
+// SnowflakeLayout uses Twitter Snowflake's field widths: a 41-bit
+// millisecond timestamp measured from Epoch, a 12-bit counter and a 10-bit
+// serverID, fitting in 63 bits so IDs stay positive and monotonic for about
+// 69 years past Epoch. Like every Layout it packs the counter above the
+// serverID, whereas Snowflake packs the worker ID above the sequence, so its
+// IDs do not decode correctly with Snowflake tooling. Callers should set
+// Epoch to a fixed point no earlier than their oldest live ID.
+var SnowflakeLayout = Layout{
+	TimestampBits: 41,
+	SubMsBits:     0,
+	CounterBits:   12,
+	ServerIDBits:  10,
+	Epoch:         time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func (l Layout) totalBits() int {
+	return l.TimestampBits + l.SubMsBits + l.CounterBits + l.ServerIDBits
+}
+
+func (l Layout) validate() error {
+	if l.TimestampBits <= 0 || l.CounterBits <= 0 || l.ServerIDBits <= 0 {
+		return fmt.Errorf("id layout must have positive timestamp, counter and server id bits")
+	}
+	if l.SubMsBits < 0 {
+		return fmt.Errorf("id layout sub-ms bits must not be negative")
+	}
+	if total := l.totalBits(); total > 64 {
+		return fmt.Errorf("id layout uses %d bits, which does not fit in 64", total)
+	}
+	return nil
+}
+
+func (l Layout) maxTimestamp() int64 {
+	return (int64(1) << uint(l.TimestampBits)) - 1
+}
+
+func (l Layout) maxCounter() int64 {
+	return (int64(1) << uint(l.CounterBits)) - 1
+}
+
+func (l Layout) maxServerID() int64 {
+	return (int64(1) << uint(l.ServerIDBits)) - 1
+}
+
+func (l Layout) maxSubMs() int64 {
+	if l.SubMsBits == 0 {
+		return 0
+	}
+	return (int64(1) << uint(l.SubMsBits)) - 1
+}
+
+func (l Layout) epochMs() int64 {
+	return l.Epoch.UnixNano() / int64(time.Millisecond)
+}
+
+// checkTimestamp reports whether ms (Unix milliseconds) still fits
+// TimestampBits once expressed relative to Epoch.
+func (l Layout) checkTimestamp(ms int64) error {
+	elapsed := ms - l.epochMs()
+	if l.SubMsBits > 0 {
+		elapsed /= 1000
+	}
+	if elapsed < 0 || elapsed > l.maxTimestamp() {
+		return fmt.Errorf("timestamp more than %d bits, ms=%v", l.TimestampBits, ms)
+	}
+	return nil
+}
+
+// pack builds an ID for ms (Unix milliseconds), counter and serverID
+// according to the layout.
+func (l Layout) pack(ms int64, counter int64, serverID int64) int64 {
+	elapsed := ms - l.epochMs()
+
+	var timestampPart, subMsPart int64
+	if l.SubMsBits > 0 {
+		timestampPart = elapsed / 1000
+		subMsPart = elapsed % 1000
+	} else {
+		timestampPart = elapsed
+	}
+
+	id := timestampPart << uint(l.SubMsBits+l.CounterBits+l.ServerIDBits)
+	id |= subMsPart << uint(l.CounterBits+l.ServerIDBits)
+	id |= counter << uint(l.ServerIDBits)
+	id |= serverID
+
+	return id
+}
+
+// Decode reverses pack, recovering the generation time, serverID and
+// counter value encoded in id.
+func (l Layout) Decode(id int64) (t time.Time, serverID int64, counter int64) {
+	serverID = id & l.maxServerID()
+	id >>= uint(l.ServerIDBits)
+
+	counter = id & l.maxCounter()
+	id >>= uint(l.CounterBits)
+
+	elapsedMs := id
+	if l.SubMsBits > 0 {
+		subMs := id & l.maxSubMs()
+		id >>= uint(l.SubMsBits)
+		elapsedMs = id*1000 + subMs
+	}
+
+	t = l.Epoch.Add(time.Duration(elapsedMs) * time.Millisecond)
+
+	return t, serverID, counter
+}
@@ -0,0 +1,67 @@
+package redis_idgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLayoutPackDecodeRoundTrip(t *testing.T) {
+	layouts := map[string]Layout{
+		"legacy":    LegacyLayout,
+		"snowflake": SnowflakeLayout,
+	}
+
+	for name, layout := range layouts {
+		t.Run(name, func(t *testing.T) {
+			ms := layout.epochMs() + 1234567
+			serverID := layout.maxServerID() - 1
+			counter := layout.maxCounter() - 1
+
+			id := layout.pack(ms, counter, serverID)
+
+			gotTime, gotServerID, gotCounter := layout.Decode(id)
+			if gotServerID != serverID {
+				t.Errorf("serverID = %d, want %d", gotServerID, serverID)
+			}
+			if gotCounter != counter {
+				t.Errorf("counter = %d, want %d", gotCounter, counter)
+			}
+
+			gotMs := gotTime.UnixNano() / int64(time.Millisecond)
+			if gotMs != ms {
+				t.Errorf("decoded ms = %d, want %d", gotMs, ms)
+			}
+		})
+	}
+}
+
+func TestLayoutValidateRejectsLayoutOverflowing64Bits(t *testing.T) {
+	l := Layout{TimestampBits: 41, SubMsBits: 10, CounterBits: 8, ServerIDBits: 14}
+	if err := l.validate(); err == nil {
+		t.Fatal("expected validate to reject a layout using more than 64 bits")
+	}
+}
+
+func TestLayoutValidateRejectsNonPositiveBits(t *testing.T) {
+	cases := []Layout{
+		{TimestampBits: 0, CounterBits: 8, ServerIDBits: 14},
+		{TimestampBits: 32, CounterBits: 0, ServerIDBits: 14},
+		{TimestampBits: 32, CounterBits: 8, ServerIDBits: 0},
+	}
+	for _, l := range cases {
+		if err := l.validate(); err == nil {
+			t.Fatalf("expected validate to reject layout %+v", l)
+		}
+	}
+}
+
+func TestLayoutCheckTimestampRejectsOverflow(t *testing.T) {
+	l := Layout{TimestampBits: 8, CounterBits: 8, ServerIDBits: 8, Epoch: time.Unix(0, 0)}
+
+	if err := l.checkTimestamp(l.epochMs() + l.maxTimestamp()); err != nil {
+		t.Fatalf("unexpected error at the boundary: %v", err)
+	}
+	if err := l.checkTimestamp(l.epochMs() + l.maxTimestamp() + 1); err == nil {
+		t.Fatal("expected checkTimestamp to reject a timestamp past the layout's range")
+	}
+}
@@ -0,0 +1,24 @@
+package redis_idgen
+
+// Option configures a generator constructed via NewIDGenerator,
+// NewClusterIDGenerator, NewIDGeneratorWithLayout, or
+// NewClusterIDGeneratorWithLayout.
+type Option func(*generator)
+
+// WithNamespace scopes all counter keys produced by the generator under ns,
+// so multiple logical ID spaces can share the same Redis keyspace/cluster
+// without their counters colliding.
+func WithNamespace(ns string) Option {
+	return func(g *generator) {
+		g.namespace = ns
+	}
+}
+
+// WithServerIDStrategy configures how GenIDFor picks a serverID for a given
+// key. It has no effect on the unkeyed GenID/GenMultiIDs calls, which always
+// pick uniformly at random.
+func WithServerIDStrategy(s ServerIDStrategy) Option {
+	return func(g *generator) {
+		g.strategy = s
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/chaos-io/core/go/logs"
@@ -14,27 +15,76 @@ import (
 	"github.com/chaos-io/idgen"
 )
 
-const (
-	maxCounter = (1 << 8) - 1
+const counterKeyExpiration = 10 * time.Minute
 
-	counterKeyExpiration = 10 * time.Minute
-)
+// redisCmdable is the subset of redis.Cmdable the generator depends on. Both
+// *redis.Client and *redis.ClusterClient (and redis.UniversalClient in
+// general) satisfy it, which is what lets NewClusterIDGenerator reuse the
+// same generator against a Redis Cluster deployment.
+type redisCmdable interface {
+	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	ScriptLoad(ctx context.Context, script string) *redis.StringCmd
+}
+
+// NewIDGenerator builds a generator using LegacyLayout (32b timestamp + 10b
+// sub-ms + 8b counter + 14b serverID). Use NewIDGeneratorWithLayout to pick a
+// different layout, e.g. SnowflakeLayout.
+func NewIDGenerator(client *redis.Client, serverIDs []int64, opts ...Option) (idgen.IIDGenerator, error) {
+	return NewIDGeneratorWithLayout(client, serverIDs, LegacyLayout, opts...)
+}
+
+// NewClusterIDGenerator is the Redis Cluster counterpart of NewIDGenerator,
+// using LegacyLayout. Use NewClusterIDGeneratorWithLayout to pick a
+// different layout, e.g. SnowflakeLayout.
+func NewClusterIDGenerator(client redis.UniversalClient, serverIDs []int64, opts ...Option) (idgen.IIDGenerator, error) {
+	return NewClusterIDGeneratorWithLayout(client, serverIDs, LegacyLayout, opts...)
+}
+
+// NewIDGeneratorWithLayout builds a generator against a standalone Redis
+// client using a caller-supplied Layout, e.g. SnowflakeLayout for IDs that
+// stay 64-bit-safe past 2038.
+func NewIDGeneratorWithLayout(client *redis.Client, serverIDs []int64, layout Layout, opts ...Option) (idgen.IIDGenerator, error) {
+	return newGenerator(client, serverIDs, layout, opts...)
+}
+
+// NewClusterIDGeneratorWithLayout is the Redis Cluster counterpart of
+// NewIDGeneratorWithLayout. counterKey hashtags every key around (namespace,
+// serverID), so all counter keys for a given server always land on the same
+// cluster slot regardless of the millisecond suffix or which layout is used.
+func NewClusterIDGeneratorWithLayout(client redis.UniversalClient, serverIDs []int64, layout Layout, opts ...Option) (idgen.IIDGenerator, error) {
+	return newGenerator(client, serverIDs, layout, opts...)
+}
 
-// NewIDGenerator 32b timestamp + 10b timestamp+ 8b counter + 14b serverID
-func NewIDGenerator(client *redis.Client, serverIDs []int64) (idgen.IIDGenerator, error) {
+func newGenerator(cli redisCmdable, serverIDs []int64, layout Layout, opts ...Option) (idgen.IIDGenerator, error) {
 	if len(serverIDs) == 0 {
 		return nil, fmt.Errorf("idgen must init with valid server ids")
 	}
-	return &generator{
-		cli:       client,
+	if err := layout.validate(); err != nil {
+		return nil, fmt.Errorf("invalid id layout: %w", err)
+	}
+
+	g := &generator{
+		cli:       cli,
 		serverIDs: serverIDs,
-	}, nil
+		layout:    layout,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g, nil
 }
 
 type generator struct {
-	cli       *redis.Client
+	cli       redisCmdable
 	serverIDs []int64
 	namespace string
+	strategy  ServerIDStrategy
+	layout    Layout
+
+	script   scriptRunner
+	ringOnce sync.Once
+	ring     *consistentHashRing
 }
 
 func (g *generator) GenID(ctx context.Context) (int64, error) {
@@ -45,16 +95,42 @@ func (g *generator) GenID(ctx context.Context) (int64, error) {
 	return ids[0], nil
 }
 
+// GenIDFor generates an ID using the serverID the configured
+// ServerIDStrategy picks for key, rather than a uniformly random one. With
+// the default StrategyRandom it behaves exactly like GenID.
+func (g *generator) GenIDFor(ctx context.Context, key string) (int64, error) {
+	serverID, err := g.pickServerIDForKey(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pick server id: %w", err)
+	}
+
+	ids, err := g.genMultiIDs(ctx, 1, serverID)
+	if err != nil {
+		return 0, logs.NewErrorw("failed to generate id", "error", err)
+	}
+	return ids[0], nil
+}
+
 func (g *generator) GenMultiIDs(ctx context.Context, counts int) ([]int64, error) {
+	serverID, err := g.pickServerID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick server id: %w", err)
+	}
+
+	return g.genMultiIDs(ctx, counts, serverID)
+}
+
+func (g *generator) genMultiIDs(ctx context.Context, counts int, serverID int64) ([]int64, error) {
 	const maxTimeAddrTimes = 8
 
+	if serverID < 0 || serverID > g.layout.maxServerID() {
+		return nil, fmt.Errorf("server id more than %d bits, serverID=%v", g.layout.ServerIDBits, serverID)
+	}
+
+	maxCounter := g.layout.maxCounter()
 	leftNum := int64(counts)
 	lastMs := int64(0)
 	ids := make([]int64, 0, counts)
-	serverID, err := g.pickServerID()
-	if err != nil {
-		return nil, fmt.Errorf("failed to pick server id: %w", err)
-	}
 
 	for idx := int64(0); leftNum > 0 && idx < maxTimeAddrTimes; idx++ {
 		ms := lo.Ternary(g.timeMS() > lastMs, g.timeMS(), lastMs)
@@ -65,46 +141,30 @@ func (g *generator) GenMultiIDs(ctx context.Context, counts int) ([]int64, error
 		lastMs = ms
 		redisKey := g.counterKey(g.namespace, serverID, ms)
 
-		counter, err := g.incrBy(ctx, redisKey, leftNum)
+		granted, newValue, err := g.script.run(ctx, g.cli, redisKey, leftNum, maxCounter, counterKeyExpiration.Milliseconds())
 		if err != nil {
 			return nil, err
 		}
 
-		var start, end int64
-
-		start = counter - leftNum
-		if start == 0 {
-			g.expire(ctx, redisKey)
-		}
-
-		if start > maxCounter {
-			continue
-		} else if counter < leftNum {
+		start := newValue - granted
+		if start < 0 {
 			return nil, fmt.Errorf("recycling of counting space occurs, ms=%v", ms)
 		}
 
-		if counter > maxCounter {
-			end = maxCounter + 1
-			leftNum = counter - maxCounter - 1
-		} else {
-			end = counter
-			leftNum = 0
+		if granted == 0 {
+			// the bucket for this millisecond is already exhausted; retry on the next tick
+			continue
 		}
+		leftNum -= granted
 
-		seconds := ms / 1000
-		millis := ms % 1000
-
-		if seconds&0xFFFFFFFF != seconds {
-			return nil, fmt.Errorf("seconds more than 32 bits, seconds=%v", seconds)
-		}
+		end := newValue
 
-		if serverID&0x3FFF != serverID {
-			return nil, fmt.Errorf("server id more than 14 bits, serverID=%v", serverID)
+		if err := g.layout.checkTimestamp(ms); err != nil {
+			return nil, err
 		}
 
 		for i := start; i < end; i++ {
-			id := (seconds)<<32 + (millis)<<22 + i<<14 + serverID
-			ids = append(ids, id)
+			ids = append(ids, g.layout.pack(ms, i, serverID))
 		}
 	}
 
@@ -115,22 +175,21 @@ func (g *generator) GenMultiIDs(ctx context.Context, counts int) ([]int64, error
 	return ids, nil
 }
 
-func (g *generator) incrBy(ctx context.Context, key string, num int64) (cntPos int64, err error) {
-	return g.cli.IncrBy(ctx, key, num).Result()
-}
-
-func (g *generator) expire(ctx context.Context, key string) {
-	_, _ = g.cli.Expire(ctx, key, counterKeyExpiration).Result()
-}
-
 func (g *generator) timeMS() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
+// counterKey hashtags the key around (namespace, serverID) so that, on a
+// Redis Cluster, every counter key for a given server routes to the same
+// slot even though the millisecond suffix changes on every call.
 func (g *generator) counterKey(space string, serverID int64, ms int64) string {
-	return fmt.Sprintf("id_generator:%v:%v:%v", space, serverID, ms)
+	return fmt.Sprintf("id_generator:{%v:%v}:%v", space, serverID, ms)
 }
 
+// pickServerID selects one of the configured server IDs at random. A
+// serverID is the unit of Redis Cluster slot affinity: because counterKey
+// hashtags on (namespace, serverID), every key for the picked server lands
+// on the same shard no matter how many times this is called.
 func (g *generator) pickServerID() (int64, error) {
 	r, err := rand.Int(rand.Reader, big.NewInt(int64(len(g.serverIDs))))
 	if err != nil {
@@ -139,3 +198,24 @@ func (g *generator) pickServerID() (int64, error) {
 
 	return g.serverIDs[r.Int64()], nil
 }
+
+// pickServerIDForKey selects a serverID for key according to the configured
+// ServerIDStrategy. StrategyRandom ignores key and behaves like
+// pickServerID.
+func (g *generator) pickServerIDForKey(key string) (int64, error) {
+	switch g.strategy {
+	case StrategyRendezvous:
+		return pickRendezvousServerID(g.serverIDs, key), nil
+	case StrategyConsistentHash:
+		return g.consistentHashRing().pick(key), nil
+	default:
+		return g.pickServerID()
+	}
+}
+
+func (g *generator) consistentHashRing() *consistentHashRing {
+	g.ringOnce.Do(func() {
+		g.ring = newConsistentHashRing(g.serverIDs, defaultVirtualNodes)
+	})
+	return g.ring
+}
@@ -0,0 +1,37 @@
+package redis_idgen
+
+import "testing"
+
+func TestCounterKeyHashtag(t *testing.T) {
+	g := &generator{}
+
+	got := g.counterKey("ns", 7, 123456)
+	want := "id_generator:{ns:7}:123456"
+	if got != want {
+		t.Fatalf("counterKey = %q, want %q", got, want)
+	}
+}
+
+func TestCounterKeySameNamespaceAndServerShareSlot(t *testing.T) {
+	g := &generator{}
+
+	a := g.counterKey("ns", 7, 1)
+	b := g.counterKey("ns", 7, 2)
+	if hashtag(a) != hashtag(b) {
+		t.Fatalf("keys for the same (namespace, serverID) must share a hashtag: %q vs %q", a, b)
+	}
+}
+
+// hashtag extracts the {...} portion of a Redis key, the part Redis Cluster
+// actually hashes to pick a slot.
+func hashtag(key string) string {
+	start := -1
+	for i, r := range key {
+		if r == '{' {
+			start = i
+		} else if r == '}' && start != -1 {
+			return key[start : i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package redis_idgen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// counterScript atomically grants a slice of a per-millisecond counter
+// bucket. It replaces the old INCRBY-then-conditional-EXPIRE sequence so a
+// crash between the two commands can no longer leave the key without a TTL,
+// and so a bucket that is already full stops consuming counter space on
+// Redis before the client ever sees it.
+//
+// KEYS[1] - the counter key
+// ARGV[1] - requested, how many IDs the caller is asking for
+// ARGV[2] - maxCounter, the highest value the bucket may reach
+// ARGV[3] - ttlMs, the PEXPIRE applied on the first write to the key
+//
+// Returns {granted, newValue}: granted is requested capped to whatever is
+// left in the bucket (min(requested, maxCounter+1-current)), and newValue is
+// the counter value after the increment.
+const counterScript = `
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local requested = tonumber(ARGV[1])
+local maxCounter = tonumber(ARGV[2])
+local ttlMs = tonumber(ARGV[3])
+
+local granted = requested
+local capacity = maxCounter + 1 - current
+if granted > capacity then
+	granted = capacity
+end
+if granted < 0 then
+	granted = 0
+end
+
+local newValue = current
+if granted > 0 then
+	newValue = redis.call('INCRBY', KEYS[1], granted)
+	if current == 0 then
+		redis.call('PEXPIRE', KEYS[1], ttlMs)
+	end
+end
+
+return {granted, newValue}
+`
+
+// scriptRunner loads counterScript once with SCRIPT LOAD and runs it with
+// EVALSHA, reloading it if Redis reports it unknown (e.g. after a restart
+// flushed the script cache).
+type scriptRunner struct {
+	mu  sync.Mutex
+	sha string
+}
+
+func (r *scriptRunner) run(ctx context.Context, cli redisCmdable, key string, requested, maxCounter, ttlMs int64) (granted, newValue int64, err error) {
+	sha, err := r.ensureLoaded(ctx, cli)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := cli.EvalSha(ctx, sha, []string{key}, requested, maxCounter, ttlMs).Result()
+	if err != nil && isNoScript(err) {
+		sha, err = r.reload(ctx, cli)
+		if err != nil {
+			return 0, 0, err
+		}
+		res, err = cli.EvalSha(ctx, sha, []string{key}, requested, maxCounter, ttlMs).Result()
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parseCounterScriptResult(res)
+}
+
+func (r *scriptRunner) ensureLoaded(ctx context.Context, cli redisCmdable) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sha != "" {
+		return r.sha, nil
+	}
+
+	sha, err := cli.ScriptLoad(ctx, counterScript).Result()
+	if err != nil {
+		return "", err
+	}
+
+	r.sha = sha
+	return r.sha, nil
+}
+
+func (r *scriptRunner) reload(ctx context.Context, cli redisCmdable) (string, error) {
+	r.mu.Lock()
+	r.sha = ""
+	r.mu.Unlock()
+
+	return r.ensureLoaded(ctx, cli)
+}
+
+func isNoScript(err error) bool {
+	return strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
+
+func parseCounterScriptResult(res interface{}) (granted, newValue int64, err error) {
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 0, 0, fmt.Errorf("unexpected counter script result: %v", res)
+	}
+
+	granted, err = toInt64(vals[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected granted value in counter script result: %w", err)
+	}
+
+	newValue, err = toInt64(vals[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected counter value in counter script result: %w", err)
+	}
+
+	return granted, newValue, nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expected int64, got %T", v)
+	}
+	return n, nil
+}
@@ -0,0 +1,27 @@
+package redis_idgen
+
+import "testing"
+
+func TestParseCounterScriptResult(t *testing.T) {
+	granted, newValue, err := parseCounterScriptResult([]interface{}{int64(5), int64(105)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if granted != 5 || newValue != 105 {
+		t.Fatalf("got granted=%d newValue=%d, want 5, 105", granted, newValue)
+	}
+}
+
+func TestParseCounterScriptResultMalformed(t *testing.T) {
+	cases := []interface{}{
+		"not a slice",
+		[]interface{}{int64(1)},
+		[]interface{}{"not-an-int", int64(1)},
+	}
+
+	for _, c := range cases {
+		if _, _, err := parseCounterScriptResult(c); err == nil {
+			t.Fatalf("expected error for result %#v", c)
+		}
+	}
+}
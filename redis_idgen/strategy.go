@@ -0,0 +1,120 @@
+package redis_idgen
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/chaos-io/idgen"
+)
+
+// ServerIDStrategy selects how a generator picks a serverID for a keyed call
+// (see IKeyedIDGenerator.GenIDFor). Unkeyed calls (GenID, GenMultiIDs) always
+// pick uniformly at random regardless of the configured strategy.
+type ServerIDStrategy int
+
+const (
+	// StrategyRandom picks uniformly at random, ignoring the key. This is
+	// the default.
+	StrategyRandom ServerIDStrategy = iota
+	// StrategyRendezvous picks the serverID with the highest
+	// hash(key, serverID) (rendezvous/HRW hashing), so the same key always
+	// routes to the same server and the mapping needs no rebuild when the
+	// server list changes.
+	StrategyRendezvous
+	// StrategyConsistentHash picks a serverID from a consistent-hash ring
+	// built over serverIDs with virtual nodes.
+	StrategyConsistentHash
+)
+
+// defaultVirtualNodes is the number of virtual nodes (replicas) placed on
+// the consistent-hash ring per real serverID.
+const defaultVirtualNodes = 100
+
+// IKeyedIDGenerator extends idgen.IIDGenerator with a call that
+// deterministically routes to a serverID based on a caller-supplied key,
+// per the configured ServerIDStrategy.
+type IKeyedIDGenerator interface {
+	idgen.IIDGenerator
+
+	// GenIDFor generates an ID using the serverID the configured
+	// ServerIDStrategy picks for key, instead of a randomly picked one.
+	GenIDFor(ctx context.Context, key string) (int64, error)
+}
+
+// pickRendezvousServerID implements rendezvous (highest random weight)
+// hashing: it computes hash(key, serverID) for every candidate and returns
+// the serverID with the highest weight.
+func pickRendezvousServerID(serverIDs []int64, key string) int64 {
+	var best int64
+	var bestWeight uint64
+
+	for i, id := range serverIDs {
+		weight := rendezvousWeight(key, id)
+		if i == 0 || weight > bestWeight {
+			bestWeight = weight
+			best = id
+		}
+	}
+
+	return best
+}
+
+func rendezvousWeight(key string, serverID int64) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{'#'})
+	_, _ = h.Write([]byte(strconv.FormatInt(serverID, 10)))
+	return h.Sum64()
+}
+
+// consistentHashRing maps keys to one of a fixed set of serverIDs using
+// consistent hashing with virtual nodes, stored as a sorted slice searched
+// with binary search.
+type consistentHashRing struct {
+	hashes  []uint64
+	nodeIDs map[uint64]int64
+}
+
+func newConsistentHashRing(serverIDs []int64, virtualNodes int) *consistentHashRing {
+	ring := &consistentHashRing{
+		nodeIDs: make(map[uint64]int64, len(serverIDs)*virtualNodes),
+	}
+
+	for _, id := range serverIDs {
+		for replica := 0; replica < virtualNodes; replica++ {
+			h := virtualNodeHash(id, replica)
+			ring.hashes = append(ring.hashes, h)
+			ring.nodeIDs[h] = id
+		}
+	}
+
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+func virtualNodeHash(serverID int64, replica int) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(serverID, 10)))
+	_, _ = h.Write([]byte{'#'})
+	_, _ = h.Write([]byte(strconv.Itoa(replica)))
+	return h.Sum64()
+}
+
+// pick returns the serverID owning the first ring position at or after
+// hash(key), wrapping around to the first position if key hashes past the
+// end of the ring.
+func (r *consistentHashRing) pick(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	target := h.Sum64()
+
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= target })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+
+	return r.nodeIDs[r.hashes[idx]]
+}
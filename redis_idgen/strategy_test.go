@@ -0,0 +1,79 @@
+package redis_idgen
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPickRendezvousServerIDIsStable(t *testing.T) {
+	serverIDs := []int64{1, 2, 3, 4, 5}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		first := pickRendezvousServerID(serverIDs, key)
+		second := pickRendezvousServerID(serverIDs, key)
+		if first != second {
+			t.Fatalf("pickRendezvousServerID(%q) not stable across calls: %d != %d", key, first, second)
+		}
+	}
+}
+
+func TestPickRendezvousServerIDUsesEveryServer(t *testing.T) {
+	serverIDs := []int64{1, 2, 3, 4}
+
+	counts := make(map[int64]int)
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		counts[pickRendezvousServerID(serverIDs, key)]++
+	}
+
+	if len(counts) != len(serverIDs) {
+		t.Fatalf("expected all %d servers to be picked at least once, got %d: %v", len(serverIDs), len(counts), counts)
+	}
+}
+
+func TestConsistentHashRingPickIsStable(t *testing.T) {
+	ring := newConsistentHashRing([]int64{1, 2, 3}, defaultVirtualNodes)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if ring.pick(key) != ring.pick(key) {
+			t.Fatalf("ring.pick(%q) not stable across calls", key)
+		}
+	}
+}
+
+func TestConsistentHashRingUsesEveryServer(t *testing.T) {
+	serverIDs := []int64{1, 2, 3}
+	ring := newConsistentHashRing(serverIDs, defaultVirtualNodes)
+
+	counts := make(map[int64]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		counts[ring.pick(key)]++
+	}
+
+	if len(counts) != len(serverIDs) {
+		t.Fatalf("expected all %d servers to be picked at least once, got %d: %v", len(serverIDs), len(counts), counts)
+	}
+}
+
+func TestConsistentHashRingStableUnderServerAddition(t *testing.T) {
+	before := newConsistentHashRing([]int64{1, 2, 3}, defaultVirtualNodes)
+	after := newConsistentHashRing([]int64{1, 2, 3, 4}, defaultVirtualNodes)
+
+	moved := 0
+	const sampleSize = 2000
+	for i := 0; i < sampleSize; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if before.pick(key) != after.pick(key) {
+			moved++
+		}
+	}
+
+	// Adding a fourth server to a three-server ring should only remand
+	// roughly 1/4 of keys, not rehash everything.
+	if moved > sampleSize/2 {
+		t.Fatalf("adding a server moved %d/%d keys, expected roughly %d", moved, sampleSize, sampleSize/4)
+	}
+}